@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every knob that used to live as a hard-coded const in the
+// #region Configs block of main.go. Precedence, lowest to highest:
+// built-in defaults < config.yaml < environment variables < CLI flags.
+type Config struct {
+	ScrollDelayMillis   int     `yaml:"scroll_delay_millis"`
+	ViewportScrollMult  float64 `yaml:"viewport_scroll_mult"`
+	LoadMoreDelayMillis int     `yaml:"load_more_delay_millis"`
+	TableName           string  `yaml:"table_name"`
+	SkipNoMcap          bool    `yaml:"skip_no_mcap"`
+	MaxRows             int     `yaml:"max_rows"`
+	ExportCSV           bool    `yaml:"export_csv"`
+
+	EnableInfluxSink bool   `yaml:"enable_influx_sink"`
+	InfluxURL        string `yaml:"influx_url"`
+	InfluxOrg        string `yaml:"influx_org"`
+	InfluxBucket     string `yaml:"influx_bucket"`
+
+	NumWorkers        int     `yaml:"num_workers"`
+	BasePort          int     `yaml:"base_port"`
+	ChromeDriverPath  string  `yaml:"chromedriver_path"`
+	HTTPRatePerSecond float64 `yaml:"http_rate_per_second"`
+	MetricsPort       int     `yaml:"metrics_port"`
+	JobTimeoutMinutes int     `yaml:"job_timeout_minutes"`
+
+	// StartDate seeds the backfill the first time tableName doesn't exist
+	// yet; StepDays is the stride between snapshot dates.
+	StartDate string `yaml:"start_date"`
+	StepDays  int    `yaml:"step_days"`
+
+	FetcherMode string `yaml:"fetcher"`
+
+	// OverrideStartDate and OverrideEndDate are per-run only (CLI flags,
+	// not config.yaml fields): when set, they replace the date range main()
+	// would otherwise compute from the table and time.Now().
+	OverrideStartDate string `yaml:"-"`
+	OverrideEndDate   string `yaml:"-"`
+
+	// DryRun fetches and validates every snapshot in range but never
+	// writes to scrape_jobs or any Sink.
+	DryRun bool `yaml:"-"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		ScrollDelayMillis:   600,
+		ViewportScrollMult:  1.4,
+		LoadMoreDelayMillis: 2000,
+		TableName:           "marketcap_snapshots",
+		SkipNoMcap:          true,
+		MaxRows:             3000,
+		ExportCSV:           true,
+
+		InfluxURL:    "http://localhost:8086",
+		InfluxOrg:    "crypto-historical",
+		InfluxBucket: "marketcap_snapshots",
+
+		NumWorkers:        4,
+		BasePort:          4444,
+		ChromeDriverPath:  "./chromedriver.exe",
+		HTTPRatePerSecond: 2,
+		MetricsPort:       9090,
+		JobTimeoutMinutes: 10,
+
+		StartDate: "2013-04-28",
+		StepDays:  7,
+
+		FetcherMode: fetcherAuto,
+	}
+}
+
+// loadConfig builds the effective Config for this run: defaults, then
+// config.yaml if it's present, then env var overrides, then CLI flags.
+// A missing config.yaml isn't an error; a malformed one is.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	raw, err := os.ReadFile("config.yaml")
+	if err == nil {
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing config.yaml: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return cfg, fmt.Errorf("reading config.yaml: %w", err)
+	}
+
+	applyEnvOverrides(&cfg)
+
+	fetcherMode := flag.String("fetcher", cfg.FetcherMode, "which fetcher to use: http, selenium, or auto")
+	chromeDriverPath := flag.String("chromedriver", cfg.ChromeDriverPath, "path to the chromedriver binary")
+	numWorkers := flag.Int("workers", cfg.NumWorkers, "number of concurrent scrape workers")
+	startDate := flag.String("override-start-date", "", "re-scrape starting from this date (YYYY-MM-DD) instead of resuming from the table")
+	endDate := flag.String("override-end-date", "", "stop enqueueing at this date (YYYY-MM-DD) instead of today")
+	dryRun := flag.Bool("dry-run", false, "fetch and validate every snapshot in range but don't write to any sink")
+	flag.Parse()
+
+	cfg.FetcherMode = *fetcherMode
+	cfg.ChromeDriverPath = *chromeDriverPath
+	cfg.NumWorkers = *numWorkers
+	cfg.OverrideStartDate = *startDate
+	cfg.OverrideEndDate = *endDate
+	cfg.DryRun = *dryRun
+
+	switch cfg.FetcherMode {
+	case fetcherHTTP, fetcherSelenium, fetcherAuto:
+	default:
+		return cfg, fmt.Errorf("invalid --fetcher value %q, must be one of: http, selenium, auto", cfg.FetcherMode)
+	}
+
+	return cfg, nil
+}
+
+// applyEnvOverrides covers knobs that are more at home as env vars in a
+// container deployment than as a CLI flag or a config.yaml edit.
+func applyEnvOverrides(cfg *Config) {
+	if v := os.Getenv("SCRAPER_TABLE_NAME"); v != "" {
+		cfg.TableName = v
+	}
+	if v := os.Getenv("SCRAPER_MAX_ROWS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MaxRows = n
+		}
+	}
+	if v := os.Getenv("SCRAPER_BASE_PORT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.BasePort = n
+		}
+	}
+	if v := os.Getenv("SCRAPER_METRICS_PORT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.MetricsPort = n
+		}
+	}
+	if v := os.Getenv("SCRAPER_JOB_TIMEOUT_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.JobTimeoutMinutes = n
+		}
+	}
+}
+
+func (c Config) scrollDelay() time.Duration {
+	return time.Duration(c.ScrollDelayMillis) * time.Millisecond
+}
+
+func (c Config) loadMoreDelay() time.Duration {
+	return time.Duration(c.LoadMoreDelayMillis) * time.Millisecond
+}
+
+func (c Config) jobTimeout() time.Duration {
+	return time.Duration(c.JobTimeoutMinutes) * time.Minute
+}
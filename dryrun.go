@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// dryRunRange resolves the date range a --dry-run walks. It never looks
+// at the database: cfg.StartDate (or --override-start-date) is the start,
+// today (or --override-end-date) is the end.
+func dryRunRange(cfg Config) (time.Time, time.Time, error) {
+	startStr := cfg.StartDate
+	if cfg.OverrideStartDate != "" {
+		startStr = cfg.OverrideStartDate
+	}
+	start, err := time.Parse("2006-01-02", startStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("parsing start date: %w", err)
+	}
+
+	end := time.Now()
+	if cfg.OverrideEndDate != "" {
+		end, err = time.Parse("2006-01-02", cfg.OverrideEndDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("parsing end date: %w", err)
+		}
+	}
+	return start, end, nil
+}
+
+// runDryRun fetches and validates every snapshot date in [start, end)
+// using the same Fetcher a real worker would, but never touches
+// scrape_jobs or any Sink — useful for sanity-checking a --fetcher choice
+// or a config change before committing to a real backfill.
+func runDryRun(ctx context.Context, cfg Config, start, end time.Time) {
+	var sharedHTTP *httpFetcher
+	if cfg.FetcherMode == fetcherHTTP || cfg.FetcherMode == fetcherAuto {
+		sharedHTTP = newHTTPFetcher(cfg.HTTPRatePerSecond)
+	}
+	fetcher := buildFetcher(cfg.FetcherMode, cfg.ChromeDriverPath, cfg.BasePort, sharedHTTP, cfg.jobTimeout())
+
+	for d := start; d.Before(end); d = d.AddDate(0, 0, cfg.StepDays) {
+		rows, err := fetcher.FetchSnapshot(ctx, d)
+		if err != nil {
+			log.Printf("[dry-run] %s: fetch failed: %v", d.Format("2006-01-02"), err)
+			continue
+		}
+		validRows, issues := validateRows(rows)
+		log.Printf("[dry-run] %s: fetched %d row(s), %d valid, %d issue(s) — nothing written", d.Format("2006-01-02"), len(rows), len(validRows), len(issues))
+	}
+}
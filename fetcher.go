@@ -0,0 +1,291 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Modes accepted by the --fetcher flag.
+const (
+	fetcherHTTP     = "http"
+	fetcherSelenium = "selenium"
+	fetcherAuto     = "auto"
+)
+
+// Fetcher retrieves the rows for a single historical snapshot date. It
+// replaces direct calls into the Selenium scraping code so a faster,
+// non-browser path can be swapped in without touching the worker loop.
+type Fetcher interface {
+	FetchSnapshot(ctx context.Context, date time.Time) ([]Row, error)
+}
+
+// errSchemaMismatch and errBlocked are returned by httpFetcher when the
+// JSON endpoint's response doesn't look like a snapshot payload anymore
+// (CMC changed the schema) or looks like a block page. Both are the
+// signal for fetcherMode "auto" to fall back to Selenium.
+var (
+	errSchemaMismatch = errors.New("httpFetcher: response did not match expected schema")
+	errBlocked        = errors.New("httpFetcher: request appears to have been blocked")
+)
+
+// ---------------- Selenium fetcher ----------------
+
+// seleniumFetcher is the original scraping path: drive a real Chrome
+// session through the historical page. port is fixed per worker so
+// concurrent fetchers don't collide on the same ChromeDriver instance.
+type seleniumFetcher struct {
+	chromeDriverPath string
+	port             int
+	jobTimeout       time.Duration
+}
+
+func newSeleniumFetcher(chromeDriverPath string, port int, jobTimeout time.Duration) *seleniumFetcher {
+	return &seleniumFetcher{chromeDriverPath: chromeDriverPath, port: port, jobTimeout: jobTimeout}
+}
+
+func (f *seleniumFetcher) FetchSnapshot(ctx context.Context, date time.Time) ([]Row, error) {
+	return scrapeWithTimeout(ctx, date, f.chromeDriverPath, f.port, f.jobTimeout)
+}
+
+// ---------------- HTTP API fetcher ----------------
+
+const cmcHistoricalAPI = "https://api.coinmarketcap.com/data-api/v3/cryptocurrency/historical"
+
+// httpFetcher calls CoinMarketCap's historical snapshot JSON endpoint
+// directly, skipping ChromeDriver and the scroll/"Load More" dance
+// entirely. It retries transient failures with backoff and rate-limits
+// itself with a token bucket so a backfill doesn't hammer the endpoint.
+type httpFetcher struct {
+	client      *http.Client
+	limiter     *tokenBucket
+	maxRetries  int
+	baseBackoff time.Duration
+}
+
+func newHTTPFetcher(requestsPerSecond float64) *httpFetcher {
+	return &httpFetcher{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		limiter:     newTokenBucket(requestsPerSecond),
+		maxRetries:  5,
+		baseBackoff: 500 * time.Millisecond,
+	}
+}
+
+func (f *httpFetcher) FetchSnapshot(ctx context.Context, date time.Time) ([]Row, error) {
+	var lastErr error
+	for attempt := 0; attempt <= f.maxRetries; attempt++ {
+		if attempt > 0 {
+			backoff := f.baseBackoff * time.Duration(1<<uint(attempt-1))
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if err := f.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		rows, err := f.fetchOnce(ctx, date)
+		if err == nil {
+			return rows, nil
+		}
+		lastErr = err
+		if errors.Is(err, errSchemaMismatch) || errors.Is(err, errBlocked) {
+			// Not worth retrying: the endpoint isn't returning what we
+			// parse, so the caller should fall back to Selenium instead.
+			return nil, err
+		}
+		log.Printf("httpFetcher: attempt %d for %s failed: %v", attempt+1, date.Format("2006-01-02"), err)
+	}
+	return nil, fmt.Errorf("httpFetcher: giving up after %d attempts: %w", f.maxRetries+1, lastErr)
+}
+
+func (f *httpFetcher) fetchOnce(ctx context.Context, date time.Time) ([]Row, error) {
+	url := fmt.Sprintf("%s?date=%s&limit=%d", cmcHistoricalAPI, date.Format("2006-01-02"), maxRows)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("doing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden {
+		return nil, errBlocked
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body := resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip reader: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	return parseHistoricalSnapshot(raw, date)
+}
+
+// historicalSnapshotResponse mirrors the fields this program actually
+// reads from CMC's historical snapshot JSON endpoint; everything else in
+// the real payload is ignored.
+type historicalSnapshotResponse struct {
+	Data struct {
+		CryptoCurrencyList []struct {
+			Rank              int64   `json:"rank"`
+			Name              string  `json:"name"`
+			Symbol            string  `json:"symbol"`
+			CirculatingSupply *int64  `json:"circulatingSupply"`
+			Quote             struct {
+				MarketCap        *float64 `json:"marketCap"`
+				Price            *float64 `json:"price"`
+				Volume24h        *float64 `json:"volume24h"`
+				PercentChange1h  *float64 `json:"percentChange1h"`
+				PercentChange24h *float64 `json:"percentChange24h"`
+				PercentChange7d  *float64 `json:"percentChange7d"`
+			} `json:"quote"`
+		} `json:"cryptoCurrencyList"`
+	} `json:"data"`
+}
+
+func parseHistoricalSnapshot(raw []byte, date time.Time) ([]Row, error) {
+	var resp historicalSnapshotResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", errSchemaMismatch, err)
+	}
+	if len(resp.Data.CryptoCurrencyList) == 0 {
+		return nil, errSchemaMismatch
+	}
+
+	rows := make([]Row, 0, len(resp.Data.CryptoCurrencyList))
+	for _, c := range resp.Data.CryptoCurrencyList {
+		if c.Quote.MarketCap == nil && skipNoMcap {
+			recordRowsSkipped("no-mcap", 1)
+			continue
+		}
+		row := Row{
+			Date:     date,
+			UnixTime: date.Unix(),
+			Rank:     c.Rank,
+			Name:     c.Name,
+			Symbol:   c.Symbol,
+		}
+		if c.Quote.MarketCap != nil {
+			row.MarketCap = sql.NullFloat64{Float64: *c.Quote.MarketCap, Valid: true}
+		}
+		if c.Quote.Price != nil {
+			row.Price = sql.NullFloat64{Float64: *c.Quote.Price, Valid: true}
+		}
+		if c.CirculatingSupply != nil {
+			row.Supply = sql.NullInt64{Int64: *c.CirculatingSupply, Valid: true}
+		}
+		if c.Quote.Volume24h != nil {
+			row.Volume = sql.NullFloat64{Float64: *c.Quote.Volume24h, Valid: true}
+		}
+		if c.Quote.PercentChange1h != nil {
+			row.HourChange = sql.NullFloat64{Float64: *c.Quote.PercentChange1h, Valid: true}
+		}
+		if c.Quote.PercentChange24h != nil {
+			row.DayChange = sql.NullFloat64{Float64: *c.Quote.PercentChange24h, Valid: true}
+		}
+		if c.Quote.PercentChange7d != nil {
+			row.WeekChange = sql.NullFloat64{Float64: *c.Quote.PercentChange7d, Valid: true}
+		}
+		rows = append(rows, row)
+		if maxRows > 0 && len(rows) >= maxRows {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// ---------------- autoFetcher ----------------
+
+// autoFetcher tries the HTTP API first and only pays for a Selenium
+// session when the API reports a schema mismatch or a block, matching
+// the --fetcher=auto flag.
+type autoFetcher struct {
+	http     *httpFetcher
+	selenium *seleniumFetcher
+}
+
+func newAutoFetcher(h *httpFetcher, sel *seleniumFetcher) *autoFetcher {
+	return &autoFetcher{http: h, selenium: sel}
+}
+
+func (f *autoFetcher) FetchSnapshot(ctx context.Context, date time.Time) ([]Row, error) {
+	rows, err := f.http.FetchSnapshot(ctx, date)
+	if err == nil {
+		return rows, nil
+	}
+	if errors.Is(err, errSchemaMismatch) || errors.Is(err, errBlocked) {
+		log.Printf("autoFetcher: falling back to Selenium for %s: %v", date.Format("2006-01-02"), err)
+		return f.selenium.FetchSnapshot(ctx, date)
+	}
+	return nil, err
+}
+
+// ---------------- token bucket ----------------
+
+// tokenBucket is a minimal rate limiter: a buffered channel refilled on a
+// ticker. wait blocks until a token is available or ctx is cancelled.
+type tokenBucket struct {
+	tokens chan struct{}
+}
+
+func newTokenBucket(ratePerSecond float64) *tokenBucket {
+	if ratePerSecond <= 0 {
+		ratePerSecond = 1
+	}
+	capacity := int(ratePerSecond)
+	if capacity < 1 {
+		capacity = 1
+	}
+	tb := &tokenBucket{tokens: make(chan struct{}, capacity)}
+	for i := 0; i < capacity; i++ {
+		tb.tokens <- struct{}{}
+	}
+	interval := time.Duration(float64(time.Second) / ratePerSecond)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			select {
+			case tb.tokens <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return tb
+}
+
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	select {
+	case <-tb.tokens:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
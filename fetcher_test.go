@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseHistoricalSnapshot(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("parses fields present in the payload", func(t *testing.T) {
+		raw := []byte(`{
+			"data": {
+				"cryptoCurrencyList": [
+					{
+						"rank": 1,
+						"name": "Bitcoin",
+						"symbol": "BTC",
+						"circulatingSupply": 19000000,
+						"quote": {
+							"marketCap": 1000000.5,
+							"price": 52000.25,
+							"volume24h": 30000.0,
+							"percentChange1h": 0.5,
+							"percentChange24h": -1.2,
+							"percentChange7d": 3.4
+						}
+					}
+				]
+			}
+		}`)
+
+		rows, err := parseHistoricalSnapshot(raw, date)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("expected 1 row, got %d", len(rows))
+		}
+		row := rows[0]
+		if row.Rank != 1 || row.Name != "Bitcoin" || row.Symbol != "BTC" {
+			t.Fatalf("unexpected row identity fields: %+v", row)
+		}
+		if !row.MarketCap.Valid || row.MarketCap.Float64 != 1000000.5 {
+			t.Fatalf("unexpected market cap: %+v", row.MarketCap)
+		}
+		if !row.Supply.Valid || row.Supply.Int64 != 19000000 {
+			t.Fatalf("unexpected supply: %+v", row.Supply)
+		}
+		if row.UnixTime != date.Unix() {
+			t.Fatalf("expected unix time %d, got %d", date.Unix(), row.UnixTime)
+		}
+	})
+
+	t.Run("missing optional fields become NULL, not zero", func(t *testing.T) {
+		raw := []byte(`{
+			"data": {
+				"cryptoCurrencyList": [
+					{
+						"rank": 2,
+						"name": "Unknown Coin",
+						"symbol": "UNK",
+						"quote": {}
+					}
+				]
+			}
+		}`)
+
+		rows, err := parseHistoricalSnapshot(raw, date)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("expected 1 row, got %d", len(rows))
+		}
+		row := rows[0]
+		if row.MarketCap.Valid || row.Price.Valid || row.Supply.Valid || row.Volume.Valid {
+			t.Fatalf("expected all optional fields invalid, got %+v", row)
+		}
+	})
+
+	t.Run("empty crypto list is a schema mismatch", func(t *testing.T) {
+		raw := []byte(`{"data": {"cryptoCurrencyList": []}}`)
+
+		_, err := parseHistoricalSnapshot(raw, date)
+		if !errors.Is(err, errSchemaMismatch) {
+			t.Fatalf("expected errSchemaMismatch, got %v", err)
+		}
+	})
+
+	t.Run("malformed JSON is a schema mismatch", func(t *testing.T) {
+		_, err := parseHistoricalSnapshot([]byte(`not json`), date)
+		if !errors.Is(err, errSchemaMismatch) {
+			t.Fatalf("expected errSchemaMismatch, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const jobsTableName = "scrape_jobs"
+
+// job statuses
+const (
+	jobStatusPending = "pending"
+	jobStatusRunning = "running"
+	jobStatusDone    = "done"
+	jobStatusError   = "error"
+)
+
+// scrapeJob is one row of scrape_jobs: a single snapshot_date to be
+// fetched, plus enough bookkeeping to retry it without restarting the
+// whole backfill.
+type scrapeJob struct {
+	ID        int64
+	Date      time.Time
+	Status    string
+	Attempts  int
+	LastError string
+	WorkerID  string
+}
+
+// ensureJobsTable creates scrape_jobs if it doesn't already exist. Jobs
+// persist across restarts so a killed program resumes where it left off
+// instead of re-walking every date.
+func ensureJobsTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	_, err := dbpool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS `+jobsTableName+` (
+			id SERIAL PRIMARY KEY,
+			snapshot_date DATE UNIQUE NOT NULL,
+			status VARCHAR(16) NOT NULL DEFAULT 'pending',
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			worker_id VARCHAR(64),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ensureJobsTable: %w", err)
+	}
+	return nil
+}
+
+// enqueueMissingDates inserts one pending job per stepDays-day step
+// between start and end (exclusive), skipping dates already present so
+// re-running the program doesn't duplicate work.
+func enqueueMissingDates(ctx context.Context, dbpool *pgxpool.Pool, start, end time.Time, stepDays int) (int, error) {
+	batch := &pgx.Batch{}
+	count := 0
+	for d := start; d.Before(end); d = d.AddDate(0, 0, stepDays) {
+		batch.Queue(`
+			INSERT INTO `+jobsTableName+` (snapshot_date)
+			VALUES ($1)
+			ON CONFLICT (snapshot_date) DO NOTHING;
+		`, d)
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	br := dbpool.SendBatch(ctx, batch)
+	defer br.Close()
+	inserted := 0
+	for i := 0; i < count; i++ {
+		tag, err := br.Exec()
+		if err != nil {
+			return 0, fmt.Errorf("enqueueMissingDates: %w", err)
+		}
+		inserted += int(tag.RowsAffected())
+	}
+	return inserted, nil
+}
+
+// reclaimStaleJobs resets jobs stuck in "running" back to "pending" if
+// they haven't been touched in longer than staleAfter. A worker that's
+// OOM-killed or crashes between claimJob and completeJob/failJob leaves
+// its row at "running" forever — claimJob's WHERE clause never selects
+// "running" rows, so without this the date would never be scraped again.
+// Call it once at startup, before any worker claims a job.
+func reclaimStaleJobs(ctx context.Context, dbpool *pgxpool.Pool, staleAfter time.Duration) (int, error) {
+	tag, err := dbpool.Exec(ctx, `
+		UPDATE `+jobsTableName+`
+		SET status = $1, worker_id = NULL, updated_at = now()
+		WHERE status = $2 AND updated_at < now() - ($3 * interval '1 second');
+	`, jobStatusPending, jobStatusRunning, staleAfter.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("reclaimStaleJobs: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// claimJob atomically claims the oldest pending (or previously-errored,
+// under maxAttempts) job for workerID using SELECT ... FOR UPDATE SKIP
+// LOCKED, so multiple workers can pop the queue concurrently without
+// double-claiming a row. Returns (nil, nil) when the queue is empty.
+func claimJob(ctx context.Context, dbpool *pgxpool.Pool, workerID string, maxAttempts int) (*scrapeJob, error) {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("claimJob: begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	row := tx.QueryRow(ctx, `
+		SELECT id, snapshot_date, status, attempts, COALESCE(last_error, '')
+		FROM `+jobsTableName+`
+		WHERE status = $1 OR (status = $2 AND attempts < $3)
+		ORDER BY snapshot_date ASC
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED;
+	`, jobStatusPending, jobStatusError, maxAttempts)
+
+	var job scrapeJob
+	if err := row.Scan(&job.ID, &job.Date, &job.Status, &job.Attempts, &job.LastError); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("claimJob: scan: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE `+jobsTableName+`
+		SET status = $1, worker_id = $2, updated_at = now()
+		WHERE id = $3;
+	`, jobStatusRunning, workerID, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("claimJob: marking running: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("claimJob: commit: %w", err)
+	}
+
+	job.WorkerID = workerID
+	return &job, nil
+}
+
+// completeJob inserts rows via insertRowsTx (the same INSERT pgxSink.Write
+// uses) and marks the job done in the same transaction, so a crash between
+// the two never leaves rows written without the job reflecting it (or vice
+// versa). The other sinks (CSV, Influx, ...) aren't transactional with
+// Postgres and are written by the caller after this returns.
+func completeJob(ctx context.Context, dbpool *pgxpool.Pool, job *scrapeJob, rows []Row) error {
+	tx, err := dbpool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("completeJob: begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertRowsTx(ctx, tx, rows); err != nil {
+		return fmt.Errorf("completeJob: inserting rows: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE `+jobsTableName+`
+		SET status = $1, attempts = attempts + 1, last_error = NULL, updated_at = now()
+		WHERE id = $2;
+	`, jobStatusDone, job.ID)
+	if err != nil {
+		return fmt.Errorf("completeJob: marking done: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// failJob records the error and bumps attempts so the job becomes
+// eligible for another worker to retry (subject to maxAttempts in
+// claimJob), instead of the whole program restarting.
+func failJob(ctx context.Context, dbpool *pgxpool.Pool, job *scrapeJob, cause error) error {
+	_, err := dbpool.Exec(ctx, `
+		UPDATE `+jobsTableName+`
+		SET status = $1, attempts = attempts + 1, last_error = $2, updated_at = now()
+		WHERE id = $3;
+	`, jobStatusError, cause.Error(), job.ID)
+	if err != nil {
+		return fmt.Errorf("failJob: %w", err)
+	}
+	return nil
+}
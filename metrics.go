@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics let a long-running backfill be watched from Grafana instead of
+// tailed through log.Println. They're all package-level since there's
+// only ever one scraper process per metrics port.
+var (
+	snapshotsProcessedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_snapshots_processed_total",
+		Help: "Snapshot dates successfully scraped and inserted.",
+	})
+
+	rowsInsertedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_rows_inserted_total",
+		Help: "Rows written to the primary table across all snapshots.",
+	})
+
+	// reasons: "no-mcap", "parse-error", "validation-failed"
+	rowsSkippedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scraper_rows_skipped_total",
+		Help: "Rows (or, for parse-error, whole failed fetches) skipped, by reason.",
+	}, []string{"reason"})
+
+	currentSnapshotDateUnix = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "scraper_current_snapshot_date_unix",
+		Help: "Unix timestamp of the snapshot_date most recently claimed by any worker.",
+	})
+
+	scrollDelayMillis = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "scraper_scroll_delay_milliseconds",
+		Help: "Scroll delay most recently settled on by a Selenium fetch (it grows at runtime when pages load too slowly).",
+	})
+
+	chromeDriverRestartsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "scraper_chromedriver_restarts_total",
+		Help: "Times a snapshot job was claimed again after an earlier attempt failed, whatever the fetcher or the cause of the failure.",
+	})
+
+	dbBatchLatencySeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "scraper_db_batch_latency_seconds",
+		Help:    "Time to execute one batch insert against Postgres.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	lastSuccessUnix = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "scraper_last_success_unix",
+		Help: "Unix timestamp of the last snapshot successfully inserted.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		snapshotsProcessedTotal,
+		rowsInsertedTotal,
+		rowsSkippedTotal,
+		currentSnapshotDateUnix,
+		scrollDelayMillis,
+		chromeDriverRestartsTotal,
+		dbBatchLatencySeconds,
+		lastSuccessUnix,
+	)
+}
+
+// startMetricsServer serves /metrics (Prometheus text format) and
+// /healthz on its own port in the background. It never blocks main() and
+// only logs if the listener itself fails to come up.
+func startMetricsServer(port int) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+
+	addr := fmt.Sprintf(":%d", port)
+	go func() {
+		log.Printf("Metrics server listening on %s", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+}
+
+func recordScrapeSuccess(rowCount int) {
+	snapshotsProcessedTotal.Inc()
+	rowsInsertedTotal.Add(float64(rowCount))
+	lastSuccessUnix.Set(float64(time.Now().Unix()))
+}
+
+func recordRowsSkipped(reason string, n int) {
+	if n <= 0 {
+		return
+	}
+	rowsSkippedTotal.WithLabelValues(reason).Add(float64(n))
+}
+
+func recordCurrentSnapshotDate(date time.Time) {
+	currentSnapshotDateUnix.Set(float64(date.Unix()))
+}
+
+func recordScrollDelay(d time.Duration) {
+	scrollDelayMillis.Set(float64(d.Milliseconds()))
+}
+
+func recordChromeDriverRestart() {
+	chromeDriverRestartsTotal.Inc()
+}
+
+func observeDBBatchLatency(start time.Time) {
+	dbBatchLatencySeconds.Observe(time.Since(start).Seconds())
+}
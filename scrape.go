@@ -0,0 +1,462 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+)
+
+// errRateLimited signals that CMC likely rate-limited the request (the
+// thead never showed up). Callers should back off and retry rather than
+// treating it as a hard failure of the snapshot date itself.
+var errRateLimited = fmt.Errorf("scrape: rate limited, retry later")
+
+// scrapeSnapshot drives a single ChromeDriver session through one
+// historical snapshot page and returns the parsed rows. It owns the full
+// lifecycle of that session (start service, open page, quit) so a worker
+// can call it once per job with its own dedicated port.
+//
+// scrapeSnapshot has no timeout of its own: ctx is the caller's
+// (scrapeWithTimeout's) job timeout, watched here only so the ChromeDriver
+// service gets Stop()ped and the session Quit() the moment the caller gives
+// up, instead of staying bound to port until whatever selenium call this
+// goroutine is blocked on eventually returns (which, for a wedged session,
+// may be never) — otherwise the next job this worker claims fails to start
+// its own service on the same port.
+func scrapeSnapshot(ctx context.Context, date time.Time, chromeDriverPath string, port int) ([]Row, error) {
+	// #region Connect to the WebDriver instance running locally
+	opts := []selenium.ServiceOption{}
+	service, err := selenium.NewChromeDriverService(chromeDriverPath, port, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("starting ChromeDriver service on port %d: %w", port, err)
+	}
+	defer service.Stop()
+
+	caps := selenium.Capabilities{"browserName": "chrome"}
+	wd, err := selenium.NewRemote(caps, fmt.Sprintf("http://localhost:%d/wd/hub", port))
+	if err != nil {
+		return nil, fmt.Errorf("opening session on port %d: %w", port, err)
+	}
+	defer wd.Quit()
+	log.Printf("ChromeDriver server started successfully on port %d", port) // #endregion
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Printf("scrape for %s timed out, tearing down ChromeDriver on port %d early", date.Format("2006-01-02"), port)
+			wd.Quit()
+			service.Stop()
+		case <-done:
+		}
+	}()
+
+	// #region Navigate to page and fully load by clicking buttons
+	url := fmt.Sprintf("https://coinmarketcap.com/historical/%d%02d%02d/", date.Year(), date.Month(), date.Day())
+	log.Println("Beginning parse for snapshot | ", date, "| ", url)
+	if err = wd.Get(url); err != nil {
+		return nil, fmt.Errorf("loading page %s: %w", url, err)
+	}
+
+	// Wait for the page to load (looks for <div class="container cmc-main-section">)
+	condition := func(wd selenium.WebDriver) (bool, error) {
+		_, err := wd.FindElement(selenium.ByCSSSelector, "div.container.cmc-main-section")
+		if err != nil {
+			if err.Error() == "no such element" {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	wd.Wait(condition)
+	log.Println("Page loaded")
+
+	clickRejectAll(wd)
+	countLoadMoreClicked := 0
+	maxClicks := int(math.Ceil(float64(maxRows)/200) - 1) // divide maxRows by 200 as Load More button populates 200 entries per click
+	for maxRows <= 0 || countLoadMoreClicked < maxClicks {
+		button, err := wd.FindElement(selenium.ByCSSSelector, "div.cmc-table-listing__loadmore > button[type='button']")
+		if err != nil {
+			if strings.Contains(err.Error(), "no such element") {
+				log.Println("\"Load More\" button not found")
+				break
+			}
+			return nil, fmt.Errorf("finding \"Load More\" button: %w", err)
+		}
+		err = button.Click()
+		if err != nil {
+			if strings.Contains(err.Error(), "click intercepted") {
+				clickRejectAll(wd)
+				continue
+			}
+			return nil, fmt.Errorf("clicking \"Load More\" button: %w", err)
+		}
+		log.Println("\"Load More\" button clicked")
+		countLoadMoreClicked += 1
+		// Weird edge case for date = 2016-07-03 where Load More button
+		// can be clicked infinitely. Suspect it is because there are
+		// exactly 600 cryptos and page stops at 600. Server side has
+		// off-by-one error?
+		//
+		// NOTE: Hard coded break after 100 clicks won't be future proof
+		// if CMC adds more than 20,000 cryptos
+		if countLoadMoreClicked > 100 {
+			break
+		}
+		time.Sleep(loadMoreDelay)
+	} // #endregion
+
+	delay := scrollDelay
+	rows, err := scrollAndParse(wd, date, &delay)
+	for err == errTooFast {
+		// scrollAndParse bumped delay itself; just retry the scroll+parse
+		// with the new value instead of restarting the whole job.
+		rows, err = scrollAndParse(wd, date, &delay)
+	}
+	recordScrollDelay(delay)
+	return rows, err
+}
+
+// errTooFast signals the scroll delay was too aggressive for this
+// snapshot and scrollAndParse should be retried with the bumped delay it
+// already recorded in *delay.
+var errTooFast = fmt.Errorf("scrape: scroll too fast, retry")
+
+// scrollAndParse scrolls the loaded page to the bottom, reads the table
+// header to find column indexes, and parses every row into a Row.
+func scrollAndParse(wd selenium.WebDriver, date time.Time, delay *time.Duration) ([]Row, error) {
+	scrollPage(*delay, wd)
+
+	// #region Iterate theads and find column indexes
+	colIndexes := make(map[string]int)
+	theads, err := wd.FindElements(selenium.ByCSSSelector, "thead")
+	if err != nil {
+		return nil, fmt.Errorf("finding thead: %w", err)
+	}
+	if len(theads) == 0 {
+		return nil, errRateLimited
+	}
+	thead := theads[2]
+	columns, err := thead.FindElements(selenium.ByCSSSelector, "th")
+	if err != nil {
+		return nil, fmt.Errorf("finding columns from thead: %w", err)
+	}
+	for i, column := range columns {
+		columnText, err := column.Text()
+		if err != nil {
+			return nil, fmt.Errorf("converting column to text: %w", err)
+		}
+		colIndexes[columnText] = i
+	}
+	if _, ok := colIndexes["Rank"]; !ok {
+		return nil, fmt.Errorf("\"Rank\" column not found in %v", colIndexes)
+	}
+
+	tbody, err := wd.FindElement(selenium.ByCSSSelector, "tbody")
+	if err != nil {
+		return nil, fmt.Errorf("finding tbody: %w", err)
+	}
+	rows, err := tbody.FindElements(selenium.ByCSSSelector, "tr")
+	if err != nil {
+		return nil, fmt.Errorf("finding row elements: %w", err)
+	} // #endregion
+
+	var queuedRows []Row
+	countRowsInserted := 0
+rowsLoop:
+	for _, row := range rows {
+		// #region Find and convert cells to data types for Row struct and append to slice
+		cells, err := row.FindElements(selenium.ByCSSSelector, "td")
+		if err != nil {
+			return nil, fmt.Errorf("finding cell elements: %w", err)
+		}
+
+		var rank int64
+		var name string
+		var symbol string
+		var marketCap float64
+		var mcapNotNull bool
+		var price float64
+		var priceNotNull bool
+		var supply int64
+		var supplyNotNull bool
+		var volume float64
+		var volumeNotNull bool
+		var hourChange float64
+		var hourNotNull bool
+		var dayChange float64
+		var dayNotNull bool
+		var weekChange float64
+		var weekNotNull bool
+		var b strings.Builder
+		if len(cells) < len(colIndexes) {
+			log.Println("index length error, scroll speed may be too fast. increasing scroll delay and retrying | ", err)
+			*delay = *delay + 50*time.Millisecond
+			return nil, errTooFast
+		}
+		if marketCapTxt, err := cells[colIndexes["Market Cap"]].Text(); err != nil {
+			return nil, fmt.Errorf("converting marketCap cell to text: %w", err)
+		} else {
+			if marketCapTxt == "--" || marketCapTxt == "" {
+				if skipNoMcap {
+					recordRowsSkipped("no-mcap", 1)
+					continue
+				}
+				marketCap = 0.0
+				mcapNotNull = false
+			} else {
+				mcapNotNull = true
+				for _, ch := range marketCapTxt {
+					switch ch {
+					case '$', ',':
+						continue
+					default:
+						b.WriteRune(ch)
+					}
+				}
+				marketCapTxt = b.String()
+				if marketCap, err = strconv.ParseFloat(marketCapTxt, 64); err != nil {
+					return nil, fmt.Errorf("ParseFloat marketCap: %w", err)
+				}
+			}
+		}
+		if rankTxt, err := cells[colIndexes["Rank"]].Text(); err != nil {
+			return nil, fmt.Errorf("converting rank cell to text: %w", err)
+		} else {
+			if rankTxt == "" {
+				return nil, fmt.Errorf("empty \"Rank\" column for row %v on %v", row, date)
+			}
+			if rank, err = strconv.ParseInt(rankTxt, 10, 64); err != nil {
+				return nil, fmt.Errorf("converting rank string to int: %w", err)
+			}
+		}
+		if name, err = cells[colIndexes["Name"]].Text(); err != nil {
+			return nil, fmt.Errorf("converting name cell to text: %w", err)
+		}
+		if symbol, err = cells[colIndexes["Symbol"]].Text(); err != nil {
+			return nil, fmt.Errorf("converting symbol cell to text: %w", err)
+		}
+		if priceTxt, err := cells[colIndexes["Price"]].Text(); err != nil {
+			return nil, fmt.Errorf("converting price cell to text: %w", err)
+		} else {
+			b.Reset()
+			for _, ch := range priceTxt {
+				switch ch {
+				case '$', ',':
+					continue
+				default:
+					b.WriteRune(ch)
+				}
+			}
+			priceTxt = b.String()
+			if priceTxt == "" || priceTxt == "--" {
+				price = 0.0
+				priceNotNull = false
+			} else {
+				if price, err = strconv.ParseFloat(priceTxt, 64); err != nil {
+					return nil, fmt.Errorf("ParseFloat price: %w", err)
+				}
+				priceNotNull = true
+			}
+		}
+		if supplyTxt, err := cells[colIndexes["Circulating Supply"]].Text(); err != nil {
+			return nil, fmt.Errorf("converting supply cell to text: %w", err)
+		} else {
+			supplyTxt, _, _ = strings.Cut(supplyTxt, " ")
+			if supplyTxt == "" || supplyTxt == "?" {
+				supplyNotNull = false
+				supply = 0
+			} else {
+				supplyNotNull = true
+				b.Reset()
+				for _, ch := range supplyTxt {
+					switch ch {
+					case ',', ' ':
+						continue
+					default:
+						b.WriteRune(ch)
+					}
+				}
+				supplyTxt = b.String()
+				if supply, err = strconv.ParseInt(supplyTxt, 10, 64); err != nil {
+					if strings.Contains(err.Error(), "value out of range") {
+						log.Println("supply too large for int64, entering null")
+						supply = 0
+						supplyNotNull = false
+					} else {
+						return nil, fmt.Errorf("ParseInt supply: %w", err)
+					}
+				}
+			}
+		}
+		if volIndex, ok := colIndexes["volume (24h)"]; ok {
+			if volumeTxt, err := cells[volIndex].Text(); err != nil {
+				return nil, fmt.Errorf("converting volume cell to text: %w", err)
+			} else {
+				b.Reset()
+				for _, ch := range volumeTxt {
+					switch ch {
+					case '$', ',':
+						continue
+					default:
+						b.WriteRune(ch)
+					}
+				}
+				volumeTxt = b.String()
+				if volumeTxt == "--" || volumeTxt == "" {
+					volumeNotNull = false
+					volume = 0
+				} else {
+					volumeNotNull = true
+					if volume, err = strconv.ParseFloat(volumeTxt, 64); err != nil {
+						return nil, fmt.Errorf("ParseFloat volume: %w", err)
+					}
+				}
+			}
+		} else {
+			volumeNotNull = false
+			volume = 0
+		}
+		if hourChange, hourNotNull, err = percTxtToFloat64(cells[7].Text()); err != nil {
+			return nil, fmt.Errorf("hour change: %w", err)
+		}
+		if dayChange, dayNotNull, err = percTxtToFloat64(cells[8].Text()); err != nil {
+			return nil, fmt.Errorf("day change: %w", err)
+		}
+		if weekChange, weekNotNull, err = percTxtToFloat64(cells[9].Text()); err != nil {
+			return nil, fmt.Errorf("week change: %w", err)
+		}
+
+		newRow := Row{
+			Date:     date,
+			UnixTime: date.Unix(),
+			Rank:     rank,
+			Name:     name,
+			Symbol:   symbol,
+			MarketCap: sql.NullFloat64{
+				Float64: marketCap,
+				Valid:   mcapNotNull,
+			},
+			Price: sql.NullFloat64{
+				Float64: price,
+				Valid:   priceNotNull,
+			},
+			Supply: sql.NullInt64{
+				Int64: supply,
+				Valid: supplyNotNull,
+			},
+			Volume: sql.NullFloat64{
+				Float64: volume,
+				Valid:   volumeNotNull,
+			},
+			HourChange: sql.NullFloat64{
+				Float64: hourChange,
+				Valid:   hourNotNull,
+			},
+			DayChange: sql.NullFloat64{
+				Float64: dayChange,
+				Valid:   dayNotNull,
+			},
+			WeekChange: sql.NullFloat64{
+				Float64: weekChange,
+				Valid:   weekNotNull,
+			},
+		}
+		queuedRows = append(queuedRows, newRow)
+		countRowsInserted += 1
+		if maxRows > 0 && countRowsInserted >= maxRows {
+			break rowsLoop
+		} // #endregion
+	}
+
+	if maxRows > 0 && len(queuedRows) >= maxRows {
+		return nil, fmt.Errorf("%d or more rows at snapshot date %s, raise max_rows in config.yaml", maxRows, date.Format("2006-01-02"))
+	}
+	return queuedRows, nil
+}
+
+// From the top, scroll down one frame at a time until it reaches the bottom.
+// This loads the dynamically populated data on the page
+func scrollPage(scrollDelay time.Duration, wd selenium.WebDriver) {
+	var err error
+	_, err = wd.ExecuteScript("window.scrollTo(0, 0);", nil)
+	if err != nil {
+		fmt.Println("Failed to scroll to top:", err)
+	}
+	// Get the height of the viewport (the visible part of the page)
+	var viewportHeight interface{}
+	viewportHeight, err = wd.ExecuteScript("return window.innerHeight;", nil)
+	if err != nil {
+		fmt.Println("Failed to get viewport height | ", err)
+	}
+	// Get the total height of the webpage
+	var bodyHeight interface{}
+	bodyHeight, err = wd.ExecuteScript("return document.body.scrollHeight;", nil)
+	if err != nil {
+		fmt.Println("Failed to get body height | ", err)
+	}
+	// Scroll down slowly, one viewport * viewportScrollMult at a time
+	scrollLength := int(viewportHeight.(float64) * viewportScrollMult)
+	for i := 0; i < int(bodyHeight.(float64)); i += scrollLength {
+		script := fmt.Sprintf("window.scrollBy(0, %d);", scrollLength)
+
+		_, err = wd.ExecuteScript(script, nil)
+		if err != nil {
+			fmt.Println("Failed to scroll:", err)
+		}
+
+		// Wait for a while to let the page load
+		time.Sleep(scrollDelay)
+	}
+	log.Println("End of page reached")
+}
+
+// Find and click "Reject All" button
+func clickRejectAll(wd selenium.WebDriver) {
+	rejectButton, err := wd.FindElement(selenium.ByCSSSelector, "#onetrust-reject-all-handler")
+	if err != nil {
+		log.Println("\"Reject All\" button not found |")
+	} else {
+		err = rejectButton.Click()
+		if err != nil {
+			log.Println("Failed to click reject button | ", err)
+		} else {
+			log.Println("\"Reject All\" button clicked")
+		}
+	}
+}
+
+// Process rows for percent change cells
+func percTxtToFloat64(text string, err error) (float64, bool, error) {
+	if err != nil {
+		return 0.0, false, fmt.Errorf("converting percent change cell to text: %w", err)
+	}
+	if text == "--" || text == "" {
+		return 0.0, false, nil
+	} else {
+		var b strings.Builder
+		for _, ch := range text {
+			switch ch {
+			case '%', ',', '<', '>', ' ':
+				continue
+			default:
+				b.WriteRune(ch)
+			}
+		}
+		text = b.String()
+		percentChange, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return 0.0, false, fmt.Errorf("ParseFloat percent change: %w", err)
+		}
+		return percentChange, true, nil
+	}
+}
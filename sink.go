@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Sink is a destination that processed rows are written to. batchInsertRows
+// and the end-of-run CSV export both used to be one-off functions; now
+// they're just Sink implementations so the program can write to several
+// destinations (tee) without the caller knowing which ones are active.
+type Sink interface {
+	Write(ctx context.Context, rows []Row) error
+	Flush() error
+	Close() error
+}
+
+// ---------------- pgx sink ----------------
+
+// pgxSink is the original behavior: batch insert into the Postgres table
+// named by tableName. completeJob (jobs.go) calls insertRowsTx directly so
+// the insert runs in the same transaction as the job-status update; pgxSink
+// itself is not part of the worker's sink tee (see runWorker in worker.go)
+// but insertRowsTx is the one place the INSERT is written, so the two paths
+// can't drift.
+type pgxSink struct {
+	pool *pgxpool.Pool
+}
+
+func newPgxSink(pool *pgxpool.Pool) *pgxSink {
+	return &pgxSink{pool: pool}
+}
+
+func (s *pgxSink) Write(ctx context.Context, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("pgxSink: begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := insertRowsTx(ctx, tx, rows); err != nil {
+		return fmt.Errorf("pgxSink: %w", err)
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *pgxSink) Flush() error { return nil }
+
+func (s *pgxSink) Close() error { return nil }
+
+// insertRowsTx batch-inserts rows into tableName using tx. It's shared by
+// pgxSink.Write and completeJob so the INSERT is defined in exactly one
+// place regardless of which transaction it runs under.
+func insertRowsTx(ctx context.Context, tx pgx.Tx, rows []Row) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	queryInsertData := `
+		INSERT INTO ` + tableName + `
+		(snapshot_date, unix_time, rank, name, symbol, market_cap, price, circulating_supply,
+			volume_24h, percent_change_1h, percent_change_24h, percent_change_7d)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+		`
+	batch := &pgx.Batch{}
+	for _, row := range rows {
+		batch.Queue(queryInsertData, row.Date, row.UnixTime, row.Rank, row.Name, row.Symbol, row.MarketCap, row.Price, row.Supply, row.Volume, row.HourChange, row.DayChange, row.WeekChange)
+	}
+	batchStart := time.Now()
+	br := tx.SendBatch(ctx, batch)
+	if _, err := br.Exec(); err != nil {
+		br.Close()
+		return fmt.Errorf("batch exec failed: %w", err)
+	}
+	if err := br.Close(); err != nil {
+		return fmt.Errorf("closing batch: %w", err)
+	}
+	observeDBBatchLatency(batchStart)
+	return nil
+}
+
+// ---------------- CSV sink ----------------
+
+// csvSink streams rows out to a CSV file as batches come in, instead of
+// waiting until the whole backfill finishes and running a single `COPY`.
+// The output is always CSV: newCSVSink creates <tableName>_<YYYY-MM-DD>.csv
+// under dirPath, and there is no Parquet output.
+//
+// runWorkers hands the same Sink slice to every worker goroutine, so Write
+// (and Flush/Close) must tolerate concurrent callers; mu serializes access
+// to the underlying *csv.Writer and wroteHeader.
+type csvSink struct {
+	mu          sync.Mutex
+	file        *os.File
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+var csvHeader = []string{
+	"snapshot_date", "unix_time", "rank", "name", "symbol", "market_cap", "price",
+	"circulating_supply", "volume_24h", "percent_change_1h", "percent_change_24h", "percent_change_7d",
+}
+
+func newCSVSink(dirPath string) (*csvSink, error) {
+	path := filepath.Join(dirPath, tableName+"_"+time.Now().Format("2006-01-02")+".csv")
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("csvSink: creating %s: %w", path, err)
+	}
+	return &csvSink{file: f, writer: csv.NewWriter(f)}, nil
+}
+
+func (s *csvSink) Write(ctx context.Context, rows []Row) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.wroteHeader {
+		if err := s.writer.Write(csvHeader); err != nil {
+			return fmt.Errorf("csvSink: writing header: %w", err)
+		}
+		s.wroteHeader = true
+	}
+	for _, row := range rows {
+		record := []string{
+			row.Date.Format("2006-01-02"),
+			strconv.FormatInt(row.UnixTime, 10),
+			strconv.FormatInt(row.Rank, 10),
+			row.Name,
+			row.Symbol,
+			nullFloatToStr(row.MarketCap),
+			nullFloatToStr(row.Price),
+			nullIntToStr(row.Supply),
+			nullFloatToStr(row.Volume),
+			nullFloatToStr(row.HourChange),
+			nullFloatToStr(row.DayChange),
+			nullFloatToStr(row.WeekChange),
+		}
+		if err := s.writer.Write(record); err != nil {
+			return fmt.Errorf("csvSink: writing row: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *csvSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *csvSink) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func nullFloatToStr(n sql.NullFloat64) string {
+	if !n.Valid {
+		return ""
+	}
+	return strconv.FormatFloat(n.Float64, 'f', -1, 64)
+}
+
+func nullIntToStr(n sql.NullInt64) string {
+	if !n.Valid {
+		return ""
+	}
+	return strconv.FormatInt(n.Int64, 10)
+}
+
+// ---------------- InfluxDB v2 sink ----------------
+
+// influxSink writes each Row as a point in the "marketcap_snapshots"
+// measurement, tagged by symbol and a coarse rank bucket so Grafana users
+// can filter/aggregate without scanning every series. Lets people who
+// already run a TSDB point it at this scraper without standing up an ETL
+// step to get snapshots out of Postgres.
+type influxSink struct {
+	client influxdb2.Client
+	writer api.WriteAPIBlocking
+}
+
+func newInfluxSink(url, token, org, bucket string) *influxSink {
+	client := influxdb2.NewClient(url, token)
+	return &influxSink{
+		client: client,
+		writer: client.WriteAPIBlocking(org, bucket),
+	}
+}
+
+// rankBucket groups ranks into buckets of 100 (e.g. "1-100", "101-200") so
+// a Grafana dashboard can filter by tier without a cardinality explosion
+// from tagging on the raw rank.
+func rankBucket(rank int64) string {
+	if rank < 1 {
+		rank = 1
+	}
+	lo := ((rank - 1) / 100) * 100 + 1
+	hi := lo + 99
+	return fmt.Sprintf("%d-%d", lo, hi)
+}
+
+func (s *influxSink) Write(ctx context.Context, rows []Row) error {
+	for _, row := range rows {
+		point := influxdb2.NewPointWithMeasurement("marketcap_snapshots").
+			AddTag("symbol", row.Symbol).
+			AddTag("rank_bucket", rankBucket(row.Rank)).
+			SetTime(row.Date)
+		if row.MarketCap.Valid {
+			point.AddField("market_cap", row.MarketCap.Float64)
+		}
+		if row.Price.Valid {
+			point.AddField("price", row.Price.Float64)
+		}
+		if row.Supply.Valid {
+			point.AddField("supply", row.Supply.Int64)
+		}
+		if row.Volume.Valid {
+			point.AddField("volume", row.Volume.Float64)
+		}
+		if row.HourChange.Valid {
+			point.AddField("percent_change_1h", row.HourChange.Float64)
+		}
+		if row.DayChange.Valid {
+			point.AddField("percent_change_24h", row.DayChange.Float64)
+		}
+		if row.WeekChange.Valid {
+			point.AddField("percent_change_7d", row.WeekChange.Float64)
+		}
+		if err := s.writer.WritePoint(ctx, point); err != nil {
+			return fmt.Errorf("influxSink: write point: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *influxSink) Flush() error { return nil }
+
+func (s *influxSink) Close() error {
+	s.client.Close()
+	return nil
+}
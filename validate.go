@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// marketCapTolerance is how far Price*Supply is allowed to disagree with
+// the scraped MarketCap before it's flagged, expressed as a fraction of
+// MarketCap (0.05 = 5%).
+const marketCapTolerance = 0.05
+
+// percentChangeFloor and percentChangeCeiling bound the percent-change
+// columns. Scraped values outside this range are parse artifacts (a
+// truncated "<" row, a misplaced decimal, ...) rather than real market
+// moves, so they're nulled instead of trusted.
+const percentChangeFloor = -100.0
+const percentChangeCeiling = 10_000.0
+
+// IssueSeverity says whether a row should still be inserted after an
+// Issue is recorded.
+type IssueSeverity string
+
+const (
+	IssueWarning IssueSeverity = "warning" // row is inserted, possibly clamped
+	IssueReject  IssueSeverity = "reject"  // row is dropped entirely
+)
+
+// Issue is one thing validateRow found wrong with a row. Every Issue is
+// written to scrape_issues regardless of severity so a bad parse can be
+// audited later instead of just vanishing.
+type Issue struct {
+	Date     time.Time
+	Symbol   string
+	Rank     int64
+	Field    string
+	Message  string
+	Severity IssueSeverity
+}
+
+// validateRow enforces invariants on a parsed row and clamps outliers
+// instead of trusting scraped text outright. It never itself decides
+// whether the row is ultimately inserted; callers do that by checking the
+// returned issues for IssueReject.
+func validateRow(r Row) (Row, []Issue) {
+	var issues []Issue
+	note := func(field, severity, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Date:     r.Date,
+			Symbol:   r.Symbol,
+			Rank:     r.Rank,
+			Field:    field,
+			Message:  fmt.Sprintf(format, args...),
+			Severity: IssueSeverity(severity),
+		})
+	}
+
+	// (d) empty or control-character symbols are almost certainly a
+	// scrape artifact (truncated cell, encoding glitch) rather than a
+	// real coin, so the row isn't worth keeping.
+	if r.Symbol == "" || containsControlRune(r.Symbol) {
+		note("symbol", string(IssueReject), "symbol is empty or contains control characters: %q", r.Symbol)
+		return r, issues
+	}
+
+	// (b) rank 0 is a parse artifact (mirrors the "never let cardinality
+	// fall below 1" rule elsewhere); bump it to 1 rather than reject the
+	// whole row.
+	if r.Rank < 1 {
+		note("rank", string(IssueWarning), "rank %d clamped to 1", r.Rank)
+		r.Rank = 1
+	}
+
+	// (a) Price * Supply should roughly equal MarketCap. When all three
+	// are present and they disagree by more than marketCapTolerance,
+	// prefer Price and Supply (read directly off their own columns) and
+	// recompute MarketCap from them rather than trust the scraped total.
+	if r.Price.Valid && r.Supply.Valid && r.MarketCap.Valid && r.MarketCap.Float64 != 0 {
+		computed := r.Price.Float64 * float64(r.Supply.Int64)
+		relDiff := math.Abs(computed-r.MarketCap.Float64) / math.Abs(r.MarketCap.Float64)
+		if relDiff > marketCapTolerance {
+			note("market_cap", string(IssueWarning), "price*supply=%.2f disagrees with market_cap=%.2f by %.1f%%, using price*supply", computed, r.MarketCap.Float64, relDiff*100)
+			r.MarketCap.Float64 = computed
+		}
+	}
+
+	// (c) percent-change columns outside a sane range are nulled rather
+	// than inserted as-is.
+	r.HourChange = clampPercentChange(r.HourChange, "percent_change_1h", note)
+	r.DayChange = clampPercentChange(r.DayChange, "percent_change_24h", note)
+	r.WeekChange = clampPercentChange(r.WeekChange, "percent_change_7d", note)
+
+	return r, issues
+}
+
+func clampPercentChange(v sql.NullFloat64, field string, note func(field, severity, format string, args ...interface{})) sql.NullFloat64 {
+	if !v.Valid {
+		return v
+	}
+	if v.Float64 < percentChangeFloor || v.Float64 > percentChangeCeiling {
+		note(field, string(IssueWarning), "value %.2f outside [%.0f, %.0f], set to null", v.Float64, percentChangeFloor, percentChangeCeiling)
+		return sql.NullFloat64{}
+	}
+	return v
+}
+
+func containsControlRune(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRows runs validateRow over a batch, returning only the rows
+// that survive (no IssueReject) alongside every issue found, so callers
+// can insert the former and audit the latter.
+func validateRows(rows []Row) ([]Row, []Issue) {
+	kept := make([]Row, 0, len(rows))
+	var allIssues []Issue
+	for _, r := range rows {
+		validated, issues := validateRow(r)
+		allIssues = append(allIssues, issues...)
+		rejected := false
+		for _, is := range issues {
+			if is.Severity == IssueReject {
+				rejected = true
+				break
+			}
+		}
+		if !rejected {
+			kept = append(kept, validated)
+		}
+	}
+	return kept, allIssues
+}
+
+const issuesTableName = "scrape_issues"
+
+// ensureIssuesTable creates scrape_issues if it doesn't already exist.
+func ensureIssuesTable(ctx context.Context, dbpool *pgxpool.Pool) error {
+	_, err := dbpool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS `+issuesTableName+` (
+			id SERIAL PRIMARY KEY,
+			snapshot_date DATE NOT NULL,
+			symbol VARCHAR(30),
+			rank INTEGER,
+			field VARCHAR(32) NOT NULL,
+			message TEXT NOT NULL,
+			severity VARCHAR(16) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("ensureIssuesTable: %w", err)
+	}
+	return nil
+}
+
+// recordIssues writes every validation issue for a batch so bad parses
+// can be audited instead of silently dropped or overwritten.
+func recordIssues(ctx context.Context, dbpool *pgxpool.Pool, issues []Issue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	batch := &pgx.Batch{}
+	for _, issue := range issues {
+		batch.Queue(`
+			INSERT INTO `+issuesTableName+` (snapshot_date, symbol, rank, field, message, severity)
+			VALUES ($1, $2, $3, $4, $5, $6);
+		`, issue.Date, issue.Symbol, issue.Rank, issue.Field, issue.Message, string(issue.Severity))
+	}
+	br := dbpool.SendBatch(ctx, batch)
+	defer br.Close()
+	for range issues {
+		if _, err := br.Exec(); err != nil {
+			return fmt.Errorf("recordIssues: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestValidateRow(t *testing.T) {
+	baseRow := func() Row {
+		return Row{
+			Date:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Rank:      1,
+			Symbol:    "BTC",
+			MarketCap: sql.NullFloat64{Float64: 1000, Valid: true},
+			Price:     sql.NullFloat64{Float64: 10, Valid: true},
+			Supply:    sql.NullInt64{Int64: 100, Valid: true},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		row        Row
+		wantReject bool
+		check      func(t *testing.T, got Row, issues []Issue)
+	}{
+		{
+			name: "clean row has no issues",
+			row:  baseRow(),
+			check: func(t *testing.T, got Row, issues []Issue) {
+				if len(issues) != 0 {
+					t.Fatalf("expected no issues, got %v", issues)
+				}
+			},
+		},
+		{
+			name: "empty symbol is rejected",
+			row: func() Row {
+				r := baseRow()
+				r.Symbol = ""
+				return r
+			}(),
+			wantReject: true,
+		},
+		{
+			name: "control character symbol is rejected",
+			row: func() Row {
+				r := baseRow()
+				r.Symbol = "BT\x00C"
+				return r
+			}(),
+			wantReject: true,
+		},
+		{
+			name: "rank below 1 is clamped to 1",
+			row: func() Row {
+				r := baseRow()
+				r.Rank = 0
+				return r
+			}(),
+			check: func(t *testing.T, got Row, issues []Issue) {
+				if got.Rank != 1 {
+					t.Fatalf("expected rank clamped to 1, got %d", got.Rank)
+				}
+				if len(issues) != 1 || issues[0].Severity != IssueWarning {
+					t.Fatalf("expected one warning issue, got %v", issues)
+				}
+			},
+		},
+		{
+			name: "market cap disagreeing with price*supply is recomputed",
+			row: func() Row {
+				r := baseRow()
+				// price*supply = 1000, market_cap claims 2000: 50% off market_cap.
+				r.MarketCap = sql.NullFloat64{Float64: 2000, Valid: true}
+				return r
+			}(),
+			check: func(t *testing.T, got Row, issues []Issue) {
+				if got.MarketCap.Float64 != 1000 {
+					t.Fatalf("expected market cap recomputed to 1000, got %v", got.MarketCap.Float64)
+				}
+				if len(issues) != 1 || issues[0].Field != "market_cap" {
+					t.Fatalf("expected one market_cap issue, got %v", issues)
+				}
+			},
+		},
+		{
+			name: "market cap within tolerance is left alone",
+			row: func() Row {
+				r := baseRow()
+				// price*supply = 1000, market_cap = 1030: 3% off, under the 5% tolerance.
+				r.MarketCap = sql.NullFloat64{Float64: 1030, Valid: true}
+				return r
+			}(),
+			check: func(t *testing.T, got Row, issues []Issue) {
+				if got.MarketCap.Float64 != 1030 {
+					t.Fatalf("expected market cap left at 1030, got %v", got.MarketCap.Float64)
+				}
+				if len(issues) != 0 {
+					t.Fatalf("expected no issues, got %v", issues)
+				}
+			},
+		},
+		{
+			name: "percent change outside bounds is nulled",
+			row: func() Row {
+				r := baseRow()
+				r.DayChange = sql.NullFloat64{Float64: 1_000_000, Valid: true}
+				return r
+			}(),
+			check: func(t *testing.T, got Row, issues []Issue) {
+				if got.DayChange.Valid {
+					t.Fatalf("expected day change nulled, got %v", got.DayChange)
+				}
+				if len(issues) != 1 || issues[0].Field != "percent_change_24h" {
+					t.Fatalf("expected one percent_change_24h issue, got %v", issues)
+				}
+			},
+		},
+		{
+			name: "percent change within bounds is kept",
+			row: func() Row {
+				r := baseRow()
+				r.HourChange = sql.NullFloat64{Float64: -5.5, Valid: true}
+				return r
+			}(),
+			check: func(t *testing.T, got Row, issues []Issue) {
+				if !got.HourChange.Valid || got.HourChange.Float64 != -5.5 {
+					t.Fatalf("expected hour change kept, got %v", got.HourChange)
+				}
+				if len(issues) != 0 {
+					t.Fatalf("expected no issues, got %v", issues)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, issues := validateRow(tt.row)
+			if tt.wantReject {
+				rejected := false
+				for _, is := range issues {
+					if is.Severity == IssueReject {
+						rejected = true
+					}
+				}
+				if !rejected {
+					t.Fatalf("expected a reject issue, got %v", issues)
+				}
+				return
+			}
+			if tt.check != nil {
+				tt.check(t, got, issues)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxJobAttempts bounds how many times in total a job is attempted (the
+// first try plus maxJobAttempts-1 retries) before a human has to look at
+// scrape_jobs.last_error themselves.
+const maxJobAttempts = 5
+
+// runWorkers spawns n worker goroutines and blocks until the scrape_jobs
+// queue is drained (no pending or retryable job left for any worker to
+// claim). fetcherMode picks how each worker fetches a snapshot: "http"
+// and "auto" share a single rate-limited httpFetcher across workers,
+// "selenium" (and the fallback path of "auto") gives each worker its own
+// ChromeDriver session on its own port starting at basePort. jobTimeout
+// bounds how long a single job (one FetchSnapshot call) gets before the
+// worker gives up on it and moves on: a per-job concern, so one stuck
+// snapshot can't reset progress on every other worker.
+func runWorkers(ctx context.Context, dbpool *pgxpool.Pool, sinks []Sink, chromeDriverPath string, n int, basePort int, fetcherMode string, httpRatePerSecond float64, jobTimeout time.Duration) {
+	var sharedHTTP *httpFetcher
+	if fetcherMode == fetcherHTTP || fetcherMode == fetcherAuto {
+		sharedHTTP = newHTTPFetcher(httpRatePerSecond)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		workerID := fmt.Sprintf("worker-%d", i)
+		port := basePort + i
+		fetcher := buildFetcher(fetcherMode, chromeDriverPath, port, sharedHTTP, jobTimeout)
+		go func() {
+			defer wg.Done()
+			runWorker(ctx, dbpool, sinks, workerID, fetcher, jobTimeout)
+		}()
+	}
+	wg.Wait()
+}
+
+// buildFetcher resolves the --fetcher flag into a concrete Fetcher for
+// one worker. sharedHTTP is reused across workers in http/auto mode so
+// they share one rate limiter instead of each getting its own budget.
+// jobTimeout is threaded into the Selenium path so scrapeWithTimeout can
+// report how long it waited before giving up.
+func buildFetcher(fetcherMode, chromeDriverPath string, port int, sharedHTTP *httpFetcher, jobTimeout time.Duration) Fetcher {
+	switch fetcherMode {
+	case fetcherHTTP:
+		return sharedHTTP
+	case fetcherSelenium:
+		return newSeleniumFetcher(chromeDriverPath, port, jobTimeout)
+	default:
+		return newAutoFetcher(sharedHTTP, newSeleniumFetcher(chromeDriverPath, port, jobTimeout))
+	}
+}
+
+// runWorker repeatedly claims a job, fetches it, and writes the result
+// until the queue has nothing left for it to claim.
+func runWorker(ctx context.Context, dbpool *pgxpool.Pool, sinks []Sink, workerID string, fetcher Fetcher, jobTimeout time.Duration) {
+	idleRounds := 0
+	for {
+		job, err := claimJob(ctx, dbpool, workerID, maxJobAttempts)
+		if err != nil {
+			log.Printf("[%s] claimJob error, backing off 30s | %v", workerID, err)
+			time.Sleep(30 * time.Second)
+			continue
+		}
+		if job == nil {
+			// Queue looked empty twice in a row with a short pause between;
+			// good enough signal nothing else is coming for this worker.
+			idleRounds++
+			if idleRounds >= 2 {
+				log.Printf("[%s] no more jobs to claim, exiting", workerID)
+				return
+			}
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		idleRounds = 0
+		recordCurrentSnapshotDate(job.Date)
+		if job.Attempts > 0 {
+			recordChromeDriverRestart()
+		}
+
+		jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+		rows, err := fetcher.FetchSnapshot(jobCtx, job.Date)
+		cancel()
+		if err != nil {
+			log.Printf("[%s] snapshot %s failed (attempt %d): %v", workerID, job.Date.Format("2006-01-02"), job.Attempts+1, err)
+			recordRowsSkipped("parse-error", 1)
+			if ferr := failJob(ctx, dbpool, job, err); ferr != nil {
+				log.Printf("[%s] failJob error: %v", workerID, ferr)
+			}
+			continue
+		}
+
+		validatedRows, issues := validateRows(rows)
+		recordRowsSkipped("validation-failed", len(rows)-len(validatedRows))
+		if err := recordIssues(ctx, dbpool, issues); err != nil {
+			log.Printf("[%s] recordIssues error for %s: %v", workerID, job.Date.Format("2006-01-02"), err)
+		}
+		rows = validatedRows
+
+		if err := completeJob(ctx, dbpool, job, rows); err != nil {
+			log.Printf("[%s] completeJob error for %s: %v", workerID, job.Date.Format("2006-01-02"), err)
+			if ferr := failJob(ctx, dbpool, job, err); ferr != nil {
+				log.Printf("[%s] failJob error: %v", workerID, ferr)
+			}
+			continue
+		}
+		recordScrapeSuccess(len(rows))
+		// Non-Postgres sinks aren't part of completeJob's transaction;
+		// write to them now that the job is durably marked done.
+		for _, sink := range sinks {
+			if _, ok := sink.(*pgxSink); ok {
+				continue
+			}
+			if err := sink.Write(ctx, rows); err != nil {
+				log.Printf("[%s] sink write error for %s: %v", workerID, job.Date.Format("2006-01-02"), err)
+			}
+		}
+		log.Printf("[%s] wrote %d rows for snapshot %s", workerID, len(rows), job.Date.Format("2006-01-02"))
+	}
+}
+
+// scrapeWithTimeout runs scrapeSnapshot and gives up if it's still running
+// when ctx is cancelled, so a single wedged ChromeDriver session (the OOM
+// case that used to reset the whole program) only costs this one job.
+// scrapeSnapshot itself watches ctx and tears its ChromeDriver session down
+// as soon as it's cancelled, so the next job on this worker can reuse port
+// without hitting an address-in-use error.
+func scrapeWithTimeout(ctx context.Context, date time.Time, chromeDriverPath string, port int, jobTimeout time.Duration) ([]Row, error) {
+	type result struct {
+		rows []Row
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		rows, err := scrapeSnapshot(ctx, date, chromeDriverPath, port)
+		done <- result{rows, err}
+	}()
+	select {
+	case r := <-done:
+		return r.rows, r.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("scrape timed out after %s: %w", jobTimeout, ctx.Err())
+	}
+}